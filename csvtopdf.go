@@ -2,48 +2,99 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
 	"flag"
 	"fmt"
-	"html"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	pdfcpu "github.com/pdfcpu/pdfcpu/pkg/api"
 )
 
-type status struct {
-	err     error
+// job is one unit of work handed to a worker: a single CSV row to render.
+// headers pairs positionally with row; it travels with the row rather than
+// being read once up front, since FieldNamer inputs can vary it per row.
+type job struct {
+	row     []string
+	headers []string
 	rowNum  int
-	pdfPath string
 }
 
 func main() {
 	//Read command line flags/args
 	templateFlag := flag.String("template", "", "path to the html template file")
-	inputFlag := flag.String("input", "", "path to the input CSV file")
-	excludeFirstFlag := flag.Bool("exclude-first", true, "exclude the first row in the CSV, commonly used for labels")
+	inputFlag := flag.String("input", "", "path to the input file (CSV, XLSX, or PDF form)")
+	inputFormatFlag := flag.String("input-format", "", "input file format: csv, xlsx, or pdf (default: infer from extension)")
+	sheetFlag := flag.String("sheet", "", "XLSX sheet name to read (default: the workbook's active sheet; XLSX only)")
+	excludeFirstFlag := flag.Bool("exclude-first", true, "exclude the first row, commonly used for labels (CSV/XLSX only)")
 	mergeOutputFlag := flag.Bool("merge-output", true, "merge all output pdfs into one pdf with multiple pages")
 	outputFlag := flag.String("output-dir", "output", "path to the directory to put output PDF files into")
 	templateDepsFlag := flag.String("template-dependencies", "", "comma separated string of dependencies for the template")
+	rendererFlag := flag.String("renderer", "chrome-exec", "PDF renderer to use: chrome-exec, chromedp, or wkhtmltopdf")
+	templateEngineFlag := flag.String("template-engine", "replacer", "template engine to use: replacer or html")
+	workersFlag := flag.Int("workers", runtime.NumCPU(), "number of rows to render concurrently")
+	retriesFlag := flag.Int("retries", 0, "number of times to retry a failed row with exponential backoff")
+	failFastFlag := flag.Bool("fail-fast", false, "stop queuing new rows as soon as one row ultimately fails")
+	encryptPasswordFlag := flag.String("encrypt-password", "", "password to encrypt the merged output PDF with")
+	watermarkFlag := flag.String("watermark", "", `watermark to stamp on every page of the merged output, e.g. "text:CONFIDENTIAL"`)
+	bookmarksFromColumnFlag := flag.Int("bookmarks-from-column", -1, "CSV column index to use as the outline/bookmark title for each merged row (-1 disables)")
+	splitEveryFlag := flag.Int("split-every", 0, "emit merged PDFs of at most this many rows each instead of one file (0 disables)")
 	flag.Parse()
 
+	if *workersFlag < 1 {
+		fmt.Println("Error: --workers must be at least 1")
+		os.Exit(1)
+	}
+
+	renderer, err := newRenderer(*rendererFlag)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	defer renderer.Close()
+
+	var watermarkText string
+	if *watermarkFlag != "" {
+		watermarkText, err = parseWatermarkSpec(*watermarkFlag)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+	}
+
 	//Read input CSV file
 	if inputFlag == nil || *inputFlag == "" {
 		fmt.Println("Error: Please specify a valid input file")
 		os.Exit(1)
 	}
 
-	csvRecords := csvReader(*inputFlag)
+	in, closeInput, err := newInput(*inputFlag, *inputFormatFlag, *sheetFlag)
+	if err != nil {
+		fmt.Println("Error opening input file: ", err)
+		os.Exit(1)
+	}
+	defer closeInput()
 
-	if *excludeFirstFlag {
-		csvRecords = csvRecords[1:]
+	// namer is non-nil when the input can report field names; for those inputs
+	// headers are re-queried per row below instead of cached once, since a PDF
+	// AcroForm's field set can differ from page to page. Other inputs either have
+	// no headers or a single fixed header row consumed here via --exclude-first.
+	namer, hasNamer := in.(FieldNamer)
+	var headers []string
+	if !hasNamer && *excludeFirstFlag {
+		headers, err = in.Next()
+		if err != nil {
+			fmt.Println("Error processing input file: ", err)
+			os.Exit(1)
+		}
 	}
 
 	//Read input template file
@@ -51,6 +102,12 @@ func main() {
 	check(err)
 	template := string(templateBytes)
 
+	engine, err := newTemplateEngine(*templateEngineFlag, template)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+
 	//create temp dir
 	_ = os.Mkdir("temp", 0755)
 	tempDir, err := filepath.Abs("temp")
@@ -78,60 +135,214 @@ func main() {
 
 	//fmt.Println("Working dir: ", tempDir)
 
-	generationResult := make(chan status, 1)
-	//for each row in input csv file
-	for i, row := range csvRecords {
-		//call goroutine with template file name, csv row, and output file name
-		go generateRecordPDF(template, row, i, tempDir, outputDir, *mergeOutputFlag, generationResult)
+	//bounded worker pool: workers pull rows off jobs and render them concurrently.
+	//runCtx is cancelled once --fail-fast sees its first failure, so the producer
+	//and in-flight renders wind down instead of queuing/finishing further rows.
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+
+	jobs := make(chan job)
+	results := make(chan rowResult)
+
+	var workerWg sync.WaitGroup
+	for w := 0; w < *workersFlag; w++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for j := range jobs {
+				result := processRow(runCtx, renderer, engine, j.row, j.headers, j.rowNum, tempDir, outputDir, *mergeOutputFlag, *retriesFlag)
+				if *bookmarksFromColumnFlag >= 0 && *bookmarksFromColumnFlag < len(j.row) {
+					result.bookmarkTitle = j.row[*bookmarksFromColumnFlag]
+				}
+				results <- result
+			}
+		}()
 	}
+	go func() {
+		workerWg.Wait()
+		close(results)
+	}()
+
+	//stream rows from the input into the job queue instead of loading them all upfront.
+	//producerErrCh carries a fatal read error (if any) out to the result loop below,
+	//so it ends up in report.json and forces a non-zero exit like any other failure.
+	producerErrCh := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		rowNum := 0
+		for {
+			row, err := in.Next()
+			if err == io.EOF {
+				producerErrCh <- nil
+				return
+			}
+			if err != nil {
+				fmt.Println("Error processing input file: ", err)
+				producerErrCh <- err
+				if *failFastFlag {
+					stopRun()
+				}
+				return
+			}
+			rowHeaders := headers
+			if hasNamer {
+				rowHeaders = namer.FieldNames()
+			}
+			select {
+			case jobs <- job{row: row, headers: rowHeaders, rowNum: rowNum}:
+			case <-runCtx.Done():
+				producerErrCh <- nil
+				return
+			}
+			rowNum++
+		}
+	}()
 
-	succeeded := make([]int, 0)
-	pdfsToMerge := make([]string, 0)
+	pdfsByRow := make(map[int]string)
+	bookmarksByRow := make(map[int]string)
+	var succeeded []int
+	var entries []reportEntry
+	anyFailed := false
 
-	for range csvRecords {
-		result := <-generationResult
+	for result := range results {
+		entry := reportEntry{
+			Row:        result.rowNum,
+			DurationMS: result.duration.Milliseconds(),
+			Retries:    result.attempts - 1,
+			StderrTail: tail(result.stderr, stderrTailBytes),
+		}
+		// Per-row PDFs only survive the run when --merge-output is off; with
+		// merging on, result.pdfPath points into tempDir, which is gone by the
+		// time anyone reads report.json, so the merged path in OutputFiles
+		// below is the only one worth recording.
+		if !*mergeOutputFlag {
+			entry.OutputPath = result.pdfPath
+		}
 		if result.err != nil {
 			fmt.Printf("Error processing row %d: ", result.rowNum)
 			fmt.Println(result.err)
+			entry.Status = "failed"
+			entry.Error = result.err.Error()
+			anyFailed = true
+			if *failFastFlag {
+				stopRun()
+			}
 		} else {
+			entry.Status = "ok"
 			succeeded = append(succeeded, result.rowNum)
-			pdfsToMerge = append(pdfsToMerge, result.pdfPath)
+			pdfsByRow[result.rowNum] = result.pdfPath
+			bookmarksByRow[result.rowNum] = result.bookmarkTitle
 		}
+		entries = append(entries, entry)
+	}
+
+	if producerErr := <-producerErrCh; producerErr != nil {
+		entries = append(entries, reportEntry{
+			Row:    -1,
+			Status: "failed",
+			Error:  "reading input file: " + producerErr.Error(),
+		})
+		anyFailed = true
 	}
 
+	//merge in row order regardless of the order workers finished in
+	sort.Ints(succeeded)
+	pdfsToMerge := make([]string, 0, len(succeeded))
+	bookmarkTitles := make([]string, 0, len(succeeded))
+	for _, rowNum := range succeeded {
+		pdfsToMerge = append(pdfsToMerge, pdfsByRow[rowNum])
+		bookmarkTitles = append(bookmarkTitles, bookmarksByRow[rowNum])
+	}
+
+	var outputFiles []string
 	if *mergeOutputFlag && len(pdfsToMerge) > 0 {
-		err = pdfcpu.MergeCreateFile(pdfsToMerge, filepath.Join(outputDir, "output.pdf"), nil)
-		if err != nil {
+		mergedPath := filepath.Join(outputDir, "output.pdf")
+		if err := pdfcpu.MergeCreateFile(pdfsToMerge, mergedPath, nil); err != nil {
 			fmt.Println(err)
+			anyFailed = true
+		} else {
+			post := &PostProcessor{
+				WatermarkText:   watermarkText,
+				EncryptPassword: *encryptPasswordFlag,
+				SplitEvery:      *splitEveryFlag,
+			}
+			if *bookmarksFromColumnFlag >= 0 {
+				post.BookmarkTitles = bookmarkTitles
+			}
+			outputs, err := post.Run(mergedPath)
+			if err != nil {
+				fmt.Println(err)
+				anyFailed = true
+			} else {
+				outputFiles = outputs
+			}
 		}
 	}
 
+	if err := writeReport(outputDir, entries, outputFiles); err != nil {
+		fmt.Println("Error writing report.json: ", err)
+	}
+
+	if anyFailed {
+		os.Exit(1)
+	}
+}
+
+// rowResult is one row's final outcome, after any retries, ready to feed both the
+// merge step and the run report.
+type rowResult struct {
+	rowNum        int
+	pdfPath       string
+	err           error
+	duration      time.Duration
+	stderr        string
+	attempts      int
+	bookmarkTitle string
 }
 
-func generateRecordPDF(templateFile string, rowFields []string, rowNum int, tempDir string, outputDir string, merge bool, retChan chan<- status) {
-	//generate array of string pairs to find and replace
-	replaceArray := make([]string, len(rowFields)*2)
-	for i, val := range rowFields {
-		replaceArray[2*i] = "<!--=" + strconv.Itoa(i) + "-->"
-		replaceArray[2*i+1] = html.EscapeString(val)
+// processRow renders a single row, retrying up to maxRetries times with exponential
+// backoff on failure (useful for transient renderer timeouts).
+func processRow(ctx context.Context, renderer Renderer, engine templateEngine, rowFields []string, headers []string, rowNum int, tempDir string, outputDir string, merge bool, maxRetries int) rowResult {
+	start := time.Now()
+	backoff := 500 * time.Millisecond
+
+	var pdfPath, stderr string
+	var err error
+	attempts := 0
+	for attempts = 1; attempts <= maxRetries+1; attempts++ {
+		pdfPath, stderr, err = renderRow(ctx, renderer, engine, rowFields, headers, rowNum, tempDir, outputDir, merge)
+		if err == nil || attempts > maxRetries {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			// --fail-fast cancelled the run while we were about to retry; stop
+			// here instead of sleeping out the full backoff on a doomed attempt.
+			return rowResult{rowNum: rowNum, pdfPath: pdfPath, err: err, duration: time.Since(start), stderr: stderr, attempts: attempts}
+		}
+		backoff *= 2
 	}
 
-	//replace template placeholders with values
-	r := strings.NewReplacer(replaceArray...)
-	injectedTemplate := r.Replace(templateFile)
+	return rowResult{rowNum: rowNum, pdfPath: pdfPath, err: err, duration: time.Since(start), stderr: stderr, attempts: attempts}
+}
+
+// renderRow performs a single render attempt for one CSV row: expand the template,
+// write it to a temp HTML file, and render that file to a PDF.
+func renderRow(ctx context.Context, renderer Renderer, engine templateEngine, rowFields []string, headers []string, rowNum int, tempDir string, outputDir string, merge bool) (pdfPath string, stderr string, err error) {
+	injectedTemplate, err := engine.Render(rowFields, headers, rowNum)
+	if err != nil {
+		return "", "", err
+	}
 
 	//write injected template to file
 	injectedFile := filepath.Join(tempDir, "injected-template-"+strconv.Itoa(rowNum)+".html")
-	//log.Printf(tempDir)
-	//log.Printf(injectedFile)
 	bytestream := []byte(injectedTemplate)
-	err := ioutil.WriteFile(injectedFile, bytestream, 0775)
-
-	if err != nil {
-		retChan <- status{err: err, rowNum: rowNum}
-		log.Fatal(err)
+	if err := ioutil.WriteFile(injectedFile, bytestream, 0775); err != nil {
+		return "", "", err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 15000*time.Millisecond)
+
+	renderCtx, cancel := context.WithTimeout(ctx, 15000*time.Millisecond)
 	defer cancel()
 
 	pdfOutputPath := outputDir
@@ -139,19 +350,13 @@ func generateRecordPDF(templateFile string, rowFields []string, rowNum int, temp
 		pdfOutputPath = tempDir
 	}
 	pdfOutputPath = filepath.Join(pdfOutputPath, strconv.Itoa(rowNum)+".pdf")
-	//log.Printf(pdfOutputPath)
-
-	cmd := exec.CommandContext(ctx, "chrome/chrome.exe", "--enable-logging", "--disable-extensions", "--headless", "--disable-gpu", "--print-to-pdf-no-header", "--run-all-compositor-stages-before-draw", "--virtual-time-budget=10000", "--print-to-pdf="+pdfOutputPath, injectedFile)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
 
+	stderr, err = renderer.RenderHTMLToPDF(renderCtx, injectedFile, pdfOutputPath)
 	if err != nil {
-		retChan <- status{err: err, rowNum: rowNum}
-		log.Fatal(err)
+		return "", stderr, err
 	}
 
-	retChan <- status{err: nil, rowNum: rowNum, pdfPath: pdfOutputPath}
+	return pdfOutputPath, stderr, nil
 }
 
 func check(e error) {
@@ -159,19 +364,3 @@ func check(e error) {
 		panic(e)
 	}
 }
-
-func csvReader(filename string) [][]string {
-	// 1. Open the file
-	recordFile, err := os.Open(filename)
-	if err != nil {
-		fmt.Println("Error reading input file: ", err)
-		os.Exit(1)
-	} // 2. Initialize the reader
-	reader := csv.NewReader(recordFile) // 3. Create reader
-	records, err := reader.ReadAll()    // 4. Read all rows in csv
-	if err != nil {
-		fmt.Println("Error processing input CSV: ", err)
-		os.Exit(1)
-	}
-	return records
-}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pdfcpu "github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/xuri/excelize/v2"
+)
+
+// Input supplies one row of field values at a time, regardless of the underlying
+// file format.
+type Input interface {
+	// Next returns the next row's values, or io.EOF once the input is exhausted.
+	Next() ([]string, error)
+}
+
+// FieldNamer is implemented by Inputs that can report the field names for the row
+// Next() most recently returned (or, before the first call to Next(), the row
+// Next() is about to return). Only the PDF AcroForm input implements it; CSV/XLSX
+// inputs do not, and take their one fixed header row from the first row instead
+// (see --exclude-first). A PDF AcroForm input may have a distinct field set per
+// page, so callers must call FieldNames() again after every Next() rather than
+// caching the first result.
+type FieldNamer interface {
+	FieldNames() []string
+}
+
+// newInput opens path and returns the Input for it, dispatching on format (one of
+// "csv", "xlsx", or "pdf"); pass "" to infer the format from path's extension.
+// sheet selects the XLSX sheet by name ("" means the workbook's active sheet) and
+// is ignored for the other formats.
+func newInput(path string, format string, sheet string) (Input, func() error, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	switch format {
+	case "csv", "":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &csvInput{reader: csv.NewReader(f)}, f.Close, nil
+	case "xlsx":
+		return newXLSXInput(path, sheet)
+	case "pdf":
+		return newPDFFormInput(path)
+	default:
+		return nil, nil, fmt.Errorf("unknown input format %q (want csv, xlsx, or pdf)", format)
+	}
+}
+
+// csvInput reads rows from a CSV file.
+type csvInput struct {
+	reader *csv.Reader
+}
+
+func (in *csvInput) Next() ([]string, error) {
+	return in.reader.Read()
+}
+
+// xlsxInput reads rows from one sheet of an XLSX workbook.
+type xlsxInput struct {
+	file *excelize.File
+	rows *excelize.Rows
+}
+
+// newXLSXInput opens path and iterates sheet, or the workbook's active sheet if
+// sheet is "".
+func newXLSXInput(path string, sheet string) (*xlsxInput, func() error, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sheet == "" {
+		sheet = f.GetSheetName(f.GetActiveSheetIndex())
+	}
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("opening sheet %q: %w", sheet, err)
+	}
+	in := &xlsxInput{file: f, rows: rows}
+	return in, f.Close, nil
+}
+
+func (in *xlsxInput) Next() ([]string, error) {
+	if !in.rows.Next() {
+		if err := in.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return in.rows.Columns()
+}
+
+// pdfFormInput reads AcroForm field values out of a PDF, treating each page's set
+// of field values as one row. fieldNamesByRow[i] holds the field names that pair
+// positionally with rows[i]'s values, since those names can differ from page to page.
+type pdfFormInput struct {
+	rows            [][]string
+	fieldNamesByRow [][]string
+	idx             int
+}
+
+// newPDFFormInput exports the PDF's AcroForm field values and buckets them into one
+// row per page, using the page pdfcpu's "forms" entry reports for each field group.
+// Forms where pdfcpu reports only one page in use come back as a single row for the
+// whole document.
+func newPDFFormInput(path string) (*pdfFormInput, func() error, error) {
+	exportPath := path + ".form.json"
+	if err := pdfcpu.ExportFormFile(path, exportPath, nil); err != nil {
+		return nil, nil, fmt.Errorf("exporting PDF form fields: %w", err)
+	}
+	defer os.Remove(exportPath)
+
+	fields, err := readPDFFormFields(exportPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, fieldNamesByRow := bucketFormFieldsByPage(fields)
+
+	return &pdfFormInput{rows: rows, fieldNamesByRow: fieldNamesByRow}, func() error { return nil }, nil
+}
+
+// FieldNames returns the field names for the row most recently returned by Next,
+// or for the row Next will return next if no row has been read yet.
+func (in *pdfFormInput) FieldNames() []string {
+	row := in.idx
+	if row > 0 {
+		row--
+	}
+	if row >= len(in.fieldNamesByRow) {
+		row = len(in.fieldNamesByRow) - 1
+	}
+	if row < 0 {
+		return nil
+	}
+	return in.fieldNamesByRow[row]
+}
+
+func (in *pdfFormInput) Next() ([]string, error) {
+	if in.idx >= len(in.rows) {
+		return nil, io.EOF
+	}
+	row := in.rows[in.idx]
+	in.idx++
+	return row, nil
+}
+
+// formField is one AcroForm field's exported value together with the page its
+// enclosing "forms" entry reports in pdfcpu's export JSON.
+type formField struct {
+	name  string
+	value string
+	page  int
+}
+
+// readPDFFormFields flattens pdfcpu's form export JSON into one formField per
+// field.
+func readPDFFormFields(exportPath string) ([]formField, error) {
+	raw, err := os.ReadFile(exportPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parsing exported form JSON: %w", err)
+	}
+
+	var fields []formField
+	flattenFormFields(doc, 1, &fields)
+	return fields, nil
+}
+
+// flattenFormFields walks the generic JSON export and records every "name"/"value"
+// (or "id"/"value") pair it finds as a formField, independent of exactly how
+// pdfcpu groups fields by type in the export schema.
+//
+// pdfcpu's ExportFormFile groups fields per page one level up from the individual
+// field objects: each entry in the top-level "forms" array is one page and carries
+// that page's number directly ("page"), with the field-type arrays (e.g.
+// "textfield", "checkbox") nested underneath it. So page is threaded down as we
+// recurse, updated whenever we pass through an object that declares one, rather
+// than read off the field object itself. A field entry that does carry its own
+// "page"/"pages" overrides the inherited value, in case a given pdfcpu version
+// puts it there instead; fields under no page information at all default to
+// page 1. (This sandbox has no network or Go module cache access to pull a
+// pdfcpu export sample or the pinned version's source to confirm the schema
+// directly — this mapping should be checked against a real multi-page export
+// before shipping.)
+func flattenFormFields(node interface{}, page int, out *[]formField) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		name, hasName := v["name"].(string)
+		if !hasName {
+			name, hasName = v["id"].(string)
+		}
+		if value, hasValue := v["value"]; hasName && hasValue {
+			*out = append(*out, formField{name: name, value: fmt.Sprintf("%v", value), page: fieldOwnPage(v, page)})
+			return
+		}
+		if p, ok := v["page"].(float64); ok {
+			page = int(p)
+		}
+		for _, child := range v {
+			flattenFormFields(child, page, out)
+		}
+	case []interface{}:
+		for _, child := range v {
+			flattenFormFields(child, page, out)
+		}
+	}
+}
+
+// fieldOwnPage returns the page a leaf field entry carries directly ("page", or
+// the first entry of "pages"), falling back to inherited when the field itself
+// carries neither.
+func fieldOwnPage(v map[string]interface{}, inherited int) int {
+	if p, ok := v["page"].(float64); ok {
+		return int(p)
+	}
+	if pages, ok := v["pages"].([]interface{}); ok && len(pages) > 0 {
+		if p, ok := pages[0].(float64); ok {
+			return int(p)
+		}
+	}
+	return inherited
+}
+
+// bucketFormFieldsByPage groups fields by the page pdfcpu recorded for them,
+// sorting field names within each page, so multi-page, repeated-field-set
+// templates yield one row per page. Forms where every field lands on the same
+// page come back as a single row. It returns the rows alongside the field
+// names belonging to each one, since different pages can carry different
+// field names.
+func bucketFormFieldsByPage(fields []formField) ([][]string, [][]string) {
+	byPage := make(map[int][]formField)
+	for _, f := range fields {
+		byPage[f.page] = append(byPage[f.page], f)
+	}
+
+	pages := make([]int, 0, len(byPage))
+	for p := range byPage {
+		pages = append(pages, p)
+	}
+	sort.Ints(pages)
+
+	rows := make([][]string, 0, len(pages))
+	fieldNamesByRow := make([][]string, 0, len(pages))
+	for _, p := range pages {
+		pageFields := byPage[p]
+		sort.Slice(pageFields, func(i, j int) bool { return pageFields[i].name < pageFields[j].name })
+		names := make([]string, len(pageFields))
+		row := make([]string, len(pageFields))
+		for i, f := range pageFields {
+			names[i] = f.name
+			row[i] = f.value
+		}
+		rows = append(rows, row)
+		fieldNamesByRow = append(fieldNamesByRow, names)
+	}
+	return rows, fieldNamesByRow
+}
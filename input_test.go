@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFlattenFormFields(t *testing.T) {
+	// Laid out the way flattenFormFields expects pdfcpu's ExportFormFile output
+	// to be grouped (see its doc comment): each entry in the top-level
+	// "forms" array is one page and carries the page number itself; the field
+	// objects nested under its "textfield"/"checkbox" arrays carry no "page" of
+	// their own.
+	const exportJSON = `{
+		"forms": [
+			{
+				"page": 1,
+				"textfield": [
+					{"id": "name", "value": "Alice"},
+					{"id": "age", "value": 30}
+				]
+			},
+			{
+				"page": 2,
+				"textfield": [
+					{"id": "name", "value": "Bob"}
+				],
+				"checkbox": [
+					{"id": "subscribed", "value": true}
+				]
+			}
+		]
+	}`
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(exportJSON), &doc); err != nil {
+		t.Fatalf("unmarshaling test fixture: %v", err)
+	}
+
+	var got []formField
+	flattenFormFields(doc, 1, &got)
+
+	want := []formField{
+		{name: "name", value: "Alice", page: 1},
+		{name: "age", value: "30", page: 1},
+		{name: "name", value: "Bob", page: 2},
+		{name: "subscribed", value: "true", page: 2},
+	}
+
+	// flattenFormFields recurses over map keys, whose iteration order Go does
+	// not guarantee, so compare as a sorted multiset rather than in sequence.
+	byNameAndPage := func(fs []formField) func(i, j int) bool {
+		return func(i, j int) bool {
+			if fs[i].page != fs[j].page {
+				return fs[i].page < fs[j].page
+			}
+			return fs[i].name < fs[j].name
+		}
+	}
+	sort.Slice(got, byNameAndPage(got))
+	sort.Slice(want, byNameAndPage(want))
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenFormFields() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFlattenFormFieldsOwnPageOverridesInherited(t *testing.T) {
+	// Defensive fallback: a leaf field that carries its own "page"/"pages" wins
+	// over the page inherited from its enclosing "forms" entry, in case a given
+	// pdfcpu version puts the page there instead of one level up.
+	const exportJSON = `{
+		"forms": [{
+			"page": 1,
+			"textfield": [
+				{"id": "name", "value": "Alice", "page": 3}
+			],
+			"checkbox": [
+				{"id": "subscribed", "value": true, "pages": [2, 4]}
+			]
+		}]
+	}`
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(exportJSON), &doc); err != nil {
+		t.Fatalf("unmarshaling test fixture: %v", err)
+	}
+
+	var got []formField
+	flattenFormFields(doc, 1, &got)
+
+	want := []formField{
+		{name: "name", value: "Alice", page: 3},
+		{name: "subscribed", value: "true", page: 2},
+	}
+
+	sort.Slice(got, func(i, j int) bool { return got[i].name < got[j].name })
+	sort.Slice(want, func(i, j int) bool { return want[i].name < want[j].name })
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenFormFields() = %+v, want %+v", got, want)
+	}
+}
+
+func TestBucketFormFieldsByPageSinglePage(t *testing.T) {
+	fields := []formField{
+		{name: "b", value: "2", page: 1},
+		{name: "a", value: "1", page: 1},
+	}
+
+	rows, fieldNamesByRow := bucketFormFieldsByPage(fields)
+
+	wantRows := [][]string{{"1", "2"}}
+	wantNames := [][]string{{"a", "b"}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("rows = %v, want %v", rows, wantRows)
+	}
+	if !reflect.DeepEqual(fieldNamesByRow, wantNames) {
+		t.Errorf("fieldNamesByRow = %v, want %v", fieldNamesByRow, wantNames)
+	}
+}
+
+func TestBucketFormFieldsByPageMultiPage(t *testing.T) {
+	// Two pages sharing a field name ("name"), each with its own value: the
+	// bug this guards against is attributing page 2's fields to page 1 (or
+	// vice versa) by sorting names across the whole document instead of
+	// grouping by the page pdfcpu actually recorded.
+	fields := []formField{
+		{name: "name", value: "Alice", page: 1},
+		{name: "age", value: "30", page: 1},
+		{name: "name", value: "Bob", page: 2},
+		{name: "age", value: "25", page: 2},
+	}
+
+	rows, fieldNamesByRow := bucketFormFieldsByPage(fields)
+
+	wantRows := [][]string{{"30", "Alice"}, {"25", "Bob"}}
+	wantNames := [][]string{{"age", "name"}, {"age", "name"}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("rows = %v, want %v", rows, wantRows)
+	}
+	if !reflect.DeepEqual(fieldNamesByRow, wantNames) {
+		t.Errorf("fieldNamesByRow = %v, want %v", fieldNamesByRow, wantNames)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestParseWatermarkSpec(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", spec: "text:CONFIDENTIAL", want: "CONFIDENTIAL"},
+		{name: "valid with colon in content", spec: "text:Page: draft", want: "Page: draft"},
+		{name: "missing prefix", spec: "CONFIDENTIAL", wantErr: true},
+		{name: "wrong prefix", spec: "image:logo.png", wantErr: true},
+		{name: "empty", spec: "", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseWatermarkSpec(c.spec)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseWatermarkSpec(%q) = %q, nil; want error", c.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWatermarkSpec(%q) returned error: %v", c.spec, err)
+			}
+			if got != c.want {
+				t.Errorf("parseWatermarkSpec(%q) = %q, want %q", c.spec, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// Renderer turns an HTML file on disk into a PDF file on disk. It returns any
+// stderr output captured during the render (truncated report-side, not here) so
+// callers can surface it on failure; backends with nothing to capture return "".
+type Renderer interface {
+	RenderHTMLToPDF(ctx context.Context, htmlPath string, outPath string) (stderr string, err error)
+	Close() error
+}
+
+// newRenderer constructs the Renderer selected by the --renderer flag.
+func newRenderer(name string) (Renderer, error) {
+	switch name {
+	case "chrome-exec":
+		return NewChromeExecRenderer()
+	case "chromedp":
+		return NewChromeDPRenderer()
+	case "wkhtmltopdf":
+		return NewWkhtmltopdfRenderer()
+	default:
+		return nil, fmt.Errorf("unknown renderer %q (want chrome-exec, chromedp, or wkhtmltopdf)", name)
+	}
+}
+
+// chromeBinaryNames are tried in order when locating a system Chrome-family binary.
+var chromeBinaryNames = []string{"chrome", "chromium", "chromium-browser", "google-chrome", "msedge"}
+
+// findChromeBinary locates a usable Chrome-family binary on PATH, falling back to the
+// legacy bundled chrome/chrome.exe path so existing Windows setups keep working.
+func findChromeBinary() (string, error) {
+	for _, name := range chromeBinaryNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	if _, err := os.Stat("chrome/chrome.exe"); err == nil {
+		return "chrome/chrome.exe", nil
+	}
+	return "", fmt.Errorf("no chrome-family binary found on PATH (tried %s) or at chrome/chrome.exe", strings.Join(chromeBinaryNames, ", "))
+}
+
+// ChromeExecRenderer forks a fresh headless Chrome process per call. This is the
+// original rendering strategy, just with cross-platform binary discovery.
+type ChromeExecRenderer struct {
+	binary string
+}
+
+// NewChromeExecRenderer locates a Chrome-family binary and returns a renderer backed by it.
+func NewChromeExecRenderer() (*ChromeExecRenderer, error) {
+	binary, err := findChromeBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &ChromeExecRenderer{binary: binary}, nil
+}
+
+func (r *ChromeExecRenderer) RenderHTMLToPDF(ctx context.Context, htmlPath string, outPath string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.binary, "--enable-logging", "--disable-extensions", "--headless", "--disable-gpu", "--print-to-pdf-no-header", "--run-all-compositor-stages-before-draw", "--virtual-time-budget=10000", "--print-to-pdf="+outPath, htmlPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+func (r *ChromeExecRenderer) Close() error { return nil }
+
+// ChromeDPRenderer drives a single long-lived Chrome instance over CDP, so rows share
+// one browser session instead of paying process startup cost per row.
+type ChromeDPRenderer struct {
+	allocCtx    context.Context
+	allocClose  context.CancelFunc
+	browserCtx  context.Context
+	browserStop context.CancelFunc
+}
+
+// NewChromeDPRenderer launches the shared browser allocator and forces the Chrome
+// process to start immediately, so every row's tab is derived from an already-running
+// browser instead of triggering its own Allocator.Allocate() on first use.
+func NewChromeDPRenderer() (*ChromeDPRenderer, error) {
+	allocCtx, allocClose := chromedp.NewExecAllocator(context.Background(), chromedp.DefaultExecAllocatorOptions[:]...)
+	browserCtx, browserStop := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserStop()
+		allocClose()
+		return nil, fmt.Errorf("launching shared chromedp browser: %w", err)
+	}
+	return &ChromeDPRenderer{allocCtx: allocCtx, allocClose: allocClose, browserCtx: browserCtx, browserStop: browserStop}, nil
+}
+
+func (r *ChromeDPRenderer) RenderHTMLToPDF(ctx context.Context, htmlPath string, outPath string) (string, error) {
+	// tabCtx must descend from r.browserCtx to inherit the already-allocated
+	// Browser, so it can't simply be derived from ctx directly; instead watch
+	// ctx's own Done channel and cancel the tab context as soon as it fires, so
+	// both ctx's deadline and an external cancellation (e.g. --fail-fast's
+	// stopRun) actually abort an in-flight render instead of running to the
+	// renderer's own timeout.
+	tabCtx, cancel := chromedp.NewContext(r.browserCtx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	absPath, err := filepath.Abs(htmlPath)
+	if err != nil {
+		return "", err
+	}
+
+	var pdfBuf []byte
+	err = chromedp.Run(tabCtx,
+		chromedp.Navigate("file://"+absPath),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var printErr error
+			pdfBuf, _, printErr = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			return printErr
+		}),
+	)
+	if err != nil {
+		return "", err
+	}
+	return "", ioutil.WriteFile(outPath, pdfBuf, 0644)
+}
+
+// Close shuts down the shared browser and its allocator.
+func (r *ChromeDPRenderer) Close() error {
+	r.browserStop()
+	r.allocClose()
+	return nil
+}
+
+// WkhtmltopdfRenderer shells out to the wkhtmltopdf binary per row, for environments
+// that already standardize on it instead of Chrome.
+type WkhtmltopdfRenderer struct {
+	binary string
+}
+
+// NewWkhtmltopdfRenderer locates the wkhtmltopdf binary on PATH.
+func NewWkhtmltopdfRenderer() (*WkhtmltopdfRenderer, error) {
+	binary, err := exec.LookPath("wkhtmltopdf")
+	if err != nil {
+		return nil, fmt.Errorf("wkhtmltopdf not found on PATH: %w", err)
+	}
+	return &WkhtmltopdfRenderer{binary: binary}, nil
+}
+
+func (r *WkhtmltopdfRenderer) RenderHTMLToPDF(ctx context.Context, htmlPath string, outPath string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.binary, htmlPath, outPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+func (r *WkhtmltopdfRenderer) Close() error { return nil }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// stderrTailBytes caps how much of a failed row's stderr ends up in the report.
+const stderrTailBytes = 4096
+
+// reportEntry is one row's outcome in the run report.
+type reportEntry struct {
+	Row        int    `json:"row"`
+	Status     string `json:"status"`
+	OutputPath string `json:"outputPath,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	Retries    int    `json:"retries"`
+	StderrTail string `json:"stderrTail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runReport is the top-level document written to report.json.
+type runReport struct {
+	Total       int           `json:"total"`
+	Succeeded   int           `json:"succeeded"`
+	Failed      int           `json:"failed"`
+	Rows        []reportEntry `json:"rows"`
+	OutputFiles []string      `json:"outputFiles,omitempty"`
+}
+
+// writeReport sorts entries by row number and writes report.json into outputDir.
+// outputFiles lists the final merged-output file(s) (plural when --split-every
+// produced multiple pieces); pass nil when there is no merged output.
+func writeReport(outputDir string, entries []reportEntry, outputFiles []string) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Row < entries[j].Row })
+
+	rep := runReport{Total: len(entries), Rows: entries, OutputFiles: outputFiles}
+	for _, e := range entries {
+		if e.Status == "ok" {
+			rep.Succeeded++
+		} else {
+			rep.Failed++
+		}
+	}
+
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, "report.json"), data, 0644)
+}
+
+// tail returns the last n bytes of s, for trimming stderr before it goes in the report.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTail(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "shorter than n", s: "abc", n: 10, want: "abc"},
+		{name: "equal to n", s: "abc", n: 3, want: "abc"},
+		{name: "longer than n", s: "abcdef", n: 3, want: "def"},
+		{name: "n is zero", s: "abc", n: 0, want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := tail(c.s, c.n); got != c.want {
+				t.Errorf("tail(%q, %d) = %q, want %q", c.s, c.n, got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	outputDir := t.TempDir()
+	entries := []reportEntry{
+		{Row: 1, Status: "ok"},
+		{Row: -1, Status: "failed", Error: "reading input file: unexpected EOF"},
+		{Row: 0, Status: "ok"},
+	}
+
+	if err := writeReport(outputDir, entries, []string{"output.pdf"}); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "report.json"))
+	if err != nil {
+		t.Fatalf("reading report.json: %v", err)
+	}
+
+	var rep runReport
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatalf("parsing report.json: %v", err)
+	}
+
+	if rep.Total != 3 {
+		t.Errorf("Total = %d, want 3", rep.Total)
+	}
+	if rep.Succeeded != 2 {
+		t.Errorf("Succeeded = %d, want 2", rep.Succeeded)
+	}
+	if rep.Failed != 1 {
+		t.Errorf("Failed = %d, want 1", rep.Failed)
+	}
+	if len(rep.Rows) != 3 || rep.Rows[0].Row != -1 || rep.Rows[1].Row != 0 || rep.Rows[2].Row != 1 {
+		t.Errorf("Rows not sorted by row number: %+v", rep.Rows)
+	}
+	if len(rep.OutputFiles) != 1 || rep.OutputFiles[0] != "output.pdf" {
+		t.Errorf("OutputFiles = %v, want [output.pdf]", rep.OutputFiles)
+	}
+}
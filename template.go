@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// templateEngine turns one CSV row into the HTML document handed to the renderer.
+// headers pairs positionally with row; it is passed per call, not fixed at
+// construction time, because some Inputs (PDF AcroForms) have field names that
+// vary from row to row.
+type templateEngine interface {
+	Render(row []string, headers []string, rowNum int) (string, error)
+}
+
+// newTemplateEngine builds the templateEngine selected by --template-engine.
+func newTemplateEngine(engine string, templateSource string) (templateEngine, error) {
+	switch engine {
+	case "replacer":
+		return &replacerEngine{template: templateSource}, nil
+	case "html":
+		tmpl, err := template.New("record").Funcs(template.FuncMap{
+			"csvField": func(row []string, i int) string {
+				if i < 0 || i >= len(row) {
+					return ""
+				}
+				return row[i]
+			},
+		}).Parse(templateSource)
+		if err != nil {
+			return nil, fmt.Errorf("parsing html template: %w", err)
+		}
+		return &htmlEngine{template: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown template engine %q (want replacer or html)", engine)
+	}
+}
+
+// replacerEngine is the original <!--=N--> comment-token scheme, kept as the default
+// for backwards compatibility with existing templates.
+type replacerEngine struct {
+	template string
+}
+
+func (e *replacerEngine) Render(row []string, headers []string, rowNum int) (string, error) {
+	replaceArray := make([]string, len(row)*2)
+	for i, val := range row {
+		replaceArray[2*i] = "<!--=" + strconv.Itoa(i) + "-->"
+		replaceArray[2*i+1] = html.EscapeString(val)
+	}
+	r := strings.NewReplacer(replaceArray...)
+	return r.Replace(e.template), nil
+}
+
+// htmlEngine executes a parsed html/template per row, giving context-aware
+// autoescaping and real control flow in place of the replacer scheme.
+type htmlEngine struct {
+	template *template.Template
+}
+
+// rowData is the value passed to Execute: .Fields is keyed by header name (when
+// headers are known), .Row is the raw field slice for headerless files, and
+// .RowNum is the zero-based row index.
+type rowData struct {
+	Fields map[string]interface{}
+	Row    []string
+	RowNum int
+}
+
+func (e *htmlEngine) Render(row []string, headers []string, rowNum int) (string, error) {
+	fields := make(map[string]interface{}, len(headers))
+	for i, header := range headers {
+		if i < len(row) {
+			fields[header] = row[i]
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := e.template.Execute(&buf, rowData{Fields: fields, Row: row, RowNum: rowNum}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestHTMLEngineRender(t *testing.T) {
+	engine, err := newTemplateEngine("html", `{{.RowNum}}: {{.Fields.name}} ({{.Fields.age}})`)
+	if err != nil {
+		t.Fatalf("newTemplateEngine: %v", err)
+	}
+
+	got, err := engine.Render([]string{"Alice", "30"}, []string{"name", "age"}, 2)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	want := "2: Alice (30)"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestHTMLEngineRenderHeadersVaryPerCall(t *testing.T) {
+	// htmlEngine takes headers per call rather than storing them at
+	// construction time, since PDF form inputs can have a different field
+	// set on every row.
+	engine, err := newTemplateEngine("html", `{{range $k, $v := .Fields}}{{$k}}={{$v}};{{end}}`)
+	if err != nil {
+		t.Fatalf("newTemplateEngine: %v", err)
+	}
+
+	first, err := engine.Render([]string{"row1val"}, []string{"name"}, 0)
+	if err != nil {
+		t.Fatalf("Render (row 0): %v", err)
+	}
+	if first != "name=row1val;" {
+		t.Errorf("Render (row 0) = %q, want %q", first, "name=row1val;")
+	}
+
+	second, err := engine.Render([]string{"row2val"}, []string{"title"}, 1)
+	if err != nil {
+		t.Fatalf("Render (row 1): %v", err)
+	}
+	if second != "title=row2val;" {
+		t.Errorf("Render (row 1) = %q, want %q", second, "title=row2val;")
+	}
+}
+
+func TestReplacerEngineRender(t *testing.T) {
+	engine, err := newTemplateEngine("replacer", `Hello <!--=0-->, age <!--=1-->`)
+	if err != nil {
+		t.Fatalf("newTemplateEngine: %v", err)
+	}
+
+	got, err := engine.Render([]string{"Alice", "30"}, nil, 0)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	want := "Hello Alice, age 30"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}